@@ -0,0 +1,43 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"encoding/base64"
+)
+
+// Encoding used for Writer.Base64Columns and Reader.Base64Columns.
+var base64Encoding = base64.StdEncoding
+
+func containsColumn(columns []int, column int) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeFields decodes the fields in r.Base64Columns. Spilled fields are
+// decoded when read through LargeField.Reader.
+func (r *Reader) decodeFields(fields []*fieldBuffer) *ParseError {
+	for _, n := range r.Base64Columns {
+		if n < 0 || n >= len(fields) {
+			continue
+		}
+		s := fields[n]
+		if s.path != "" {
+			s.base64 = true
+			continue
+		}
+		decoded, err := base64Encoding.DecodeString(s.String())
+		if err != nil {
+			return &ParseError{Column: n, Err: err}
+		}
+		s.mem.Reset()
+		s.mem.Write(decoded)
+		s.size = int64(len(decoded))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func binaryField() string {
+	b := make([]byte, 256)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return string(b)
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	records := [][]string{
+		{"a", binaryField(), "b c"},
+		{"d", "line\nbreak, \"quoted\"", "g"},
+		{"e", "", "f"},
+	}
+	b := new(bytes.Buffer)
+	w := NewDialectWriter(b, Dialect{Delimiter: ','})
+	w.Base64Columns = []int{1, 5}
+	w.WriteAll(records)
+
+	if lines := strings.Count(b.String(), "\n"); lines != 3 {
+		t.Error("Expected encoded columns not to contain line breaks:", lines)
+	}
+	if strings.Contains(b.String(), "\"") {
+		t.Error("Expected encoded columns not to need quoting:", b.String())
+	}
+
+	r := NewDialectReader(b, Dialect{Delimiter: ','})
+	r.Base64Columns = []int{1, 5}
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(data, records) {
+		t.Errorf("Unexpected output: %q", data)
+	}
+}
+
+func TestBase64Checksummed(t *testing.T) {
+	t.Parallel()
+
+	records := [][]string{{"a", binaryField()}, {"b", "c"}}
+	b := new(bytes.Buffer)
+	w, err := NewChecksummedWriter(b, Dialect{}, ChecksumCRC32)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	w.Base64Columns = []int{1}
+	if err := w.WriteAll(records); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	r := NewReader(b)
+	r.Base64Columns = []int{1}
+	r.ExpectChecksum = ChecksumCRC32
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(data, records) {
+		t.Errorf("Unexpected output: %q", data)
+	}
+}
+
+func TestBase64DecodingError(t *testing.T) {
+	t.Parallel()
+
+	r := NewDialectReader(strings.NewReader("a,YWJj\nb,!!!\nc,ZGVm\n"), Dialect{Delimiter: ','})
+	r.Base64Columns = []int{1}
+	if err := testReadingSingleLine(t, r, []string{"a", "abc"}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	_, err := r.Read()
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 2 || perr.Column != 1 {
+		t.Error("Unexpected error:", err)
+	}
+	if err := testReadingSingleLine(t, r, []string{"c", "def"}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestBase64Spilled(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "csv-test-")
+	if err != nil {
+		t.Fatal("Could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := new(bytes.Buffer)
+	w := NewWriter(b)
+	w.Base64Columns = []int{0}
+	w.Write([]string{binaryField()})
+	w.Flush()
+
+	r := NewReader(b)
+	r.Base64Columns = []int{0}
+	r.SpillThreshold = 16
+	r.SpillDir = dir
+	defer r.Close()
+
+	record, err := r.ReadLarge()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if !record[0].Spilled() {
+		t.Fatal("Expected field to be spilled.")
+	}
+	field, err := record[0].Reader()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	defer field.Close()
+	if data, err := ioutil.ReadAll(field); err != nil || string(data) != binaryField() {
+		t.Errorf("Unexpected spilled field: %q %v", data, err)
+	}
+}
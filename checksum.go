@@ -0,0 +1,168 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Checksum algorithms supported by ChecksummedWriter and
+// Reader.ExpectChecksum.
+const (
+	ChecksumCRC32  = "crc32"  // IEEE CRC-32.
+	ChecksumSHA256 = "sha256" // SHA-256.
+)
+
+// First field of the trailer record holding the checksum. The second field is
+// the hex encoded checksum.
+const ChecksumField = "__checksum__"
+
+var (
+	ErrUnknownChecksum     = errors.New("Unknown checksum algorithm.")
+	ErrChecksumMismatch    = errors.New("Checksum does not match data.")
+	ErrChecksumMissing     = errors.New("Checksum trailer is missing.")
+	ErrChecksumWritten     = errors.New("Checksum trailer has already been written.")
+	ErrChecksumReserved    = errors.New("Record can not start with the checksum field.")
+	ErrChecksumUnsupported = errors.New("Checksums can not be verified when reading large fields.")
+)
+
+func newChecksum(algo string) (hash.Hash, error) {
+	switch algo {
+	case ChecksumCRC32:
+		return crc32.NewIEEE(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	}
+	return nil, ErrUnknownChecksum
+}
+
+// A ChecksummedWriter is a Writer that appends a trailer record containing a
+// checksum when flushed. The trailer looks like
+//
+//	__checksum__,<hex>
+//
+// using the delimiter, quoting and line terminator of the dialect. The
+// checksum covers every byte written before the trailer, that is all records
+// including their delimiters, quotes and line terminators.
+//
+// Since the trailer ends the stream, Flush should only be called once all
+// records have been written. Writing more records afterwards fails with
+// ErrChecksumWritten. Records starting with ChecksumField are rejected with
+// ErrChecksumReserved, since they could be mistaken for the trailer.
+type ChecksummedWriter struct {
+	Writer
+	out  io.Writer
+	hash hash.Hash
+	done bool
+	err  error
+}
+
+// Create a CSV writer that appends a checksum trailer using algo, which is
+// one of the `Checksum*` constants.
+func NewChecksummedWriter(w io.Writer, opts Dialect, algo string) (*ChecksummedWriter, error) {
+	h, err := newChecksum(algo)
+	if err != nil {
+		return nil, err
+	}
+	return &ChecksummedWriter{
+		Writer: NewDialectWriter(io.MultiWriter(w, h), opts),
+		out:    w,
+		hash:   h,
+	}, nil
+}
+
+// Error reports any error that has occurred during a previous Write or Flush.
+func (w *ChecksummedWriter) Error() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.Writer.Error()
+}
+
+// Flush writes any buffered data followed by the checksum trailer to the
+// underlying io.Writer. To check if an error occurred during the Flush, call
+// Error.
+func (w *ChecksummedWriter) Flush() {
+	if err := w.w.Flush(); err != nil || w.done {
+		return
+	}
+	w.done = true
+
+	// Bypassing the hash; the trailer is not part of the checksummed data.
+	trailer := NewDialectWriter(w.out, w.opts)
+	if w.err = trailer.Write([]string{ChecksumField, hex.EncodeToString(w.hash.Sum(nil))}); w.err != nil {
+		return
+	}
+	w.err = trailer.w.Flush()
+}
+
+// Writer writes a single CSV record to w along with any necessary quoting.
+// A record is a slice of strings with each string being one field.
+func (w *ChecksummedWriter) Write(record []string) error {
+	if w.done {
+		return ErrChecksumWritten
+	}
+	if len(record) > 0 && record[0] == ChecksumField {
+		return ErrChecksumReserved
+	}
+	return w.Writer.Write(record)
+}
+
+// WriteAll writes multiple CSV records to w using Write and then calls Flush.
+func (w *ChecksummedWriter) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// isTrailer reports whether fields, of which at least the first one has been
+// read, might be a checksum trailer that r has to verify.
+func (r *Reader) isTrailer(fields []*fieldBuffer) bool {
+	return r.ExpectChecksum != "" && len(fields) > 0 && fields[0].String() == ChecksumField
+}
+
+// readChecksummed reads a record while feeding its raw bytes into the
+// checksum. The trailer record is verified and never returned.
+func (r *Reader) readChecksummed() ([]*fieldBuffer, error) {
+	if r.checksumDone {
+		return nil, io.EOF
+	}
+	if r.checksum == nil {
+		h, err := newChecksum(r.ExpectChecksum)
+		if err != nil {
+			return nil, err
+		}
+		r.checksum = h
+		r.r.rec = new(bytes.Buffer)
+	}
+
+	fields, err := r.readFields(false)
+	if len(fields) == 2 && fields[0].String() == ChecksumField && (err == nil || err == io.EOF) {
+		r.checksumDone = true
+		if fields[1].String() != hex.EncodeToString(r.checksum.Sum(nil)) {
+			return nil, ErrChecksumMismatch
+		}
+		// Anything following the trailer is not covered by it.
+		if _, _, err := r.r.ReadRune(); err != io.EOF {
+			return nil, ErrChecksumMismatch
+		}
+		return nil, io.EOF
+	}
+	if err == io.EOF {
+		r.checksumDone = true
+		return fields, ErrChecksumMissing
+	}
+	r.checksum.Write(r.r.rec.Bytes())
+	return fields, err
+}
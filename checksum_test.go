@@ -0,0 +1,146 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var checksumRecords = [][]string{
+	{"a", "b c", "d"},
+	{"e", "f\"g", "h"},
+}
+
+func writeChecksummed(t *testing.T, algo string) *bytes.Buffer {
+	b := new(bytes.Buffer)
+	w, err := NewChecksummedWriter(b, Dialect{}, algo)
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err := w.WriteAll(checksumRecords); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	return b
+}
+
+func TestChecksummedWriter(t *testing.T) {
+	t.Parallel()
+
+	b := writeChecksummed(t, ChecksumCRC32)
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != 4 {
+		t.Fatal("Unexpected output:", b.String())
+	}
+	if data := strings.Join(lines[:2], "\n") + "\n"; data != "a \"b c\" d\ne \"f\"\"g\" h\n" {
+		t.Error("Unexpected data:", data)
+	}
+	if !strings.HasPrefix(lines[2], ChecksumField+" ") {
+		t.Error("Unexpected trailer:", lines[2])
+	}
+
+	w, _ := NewChecksummedWriter(new(bytes.Buffer), Dialect{}, ChecksumCRC32)
+	if err := w.Write([]string{ChecksumField, "data"}); err != ErrChecksumReserved {
+		t.Error("Expected ErrChecksumReserved, got:", err)
+	}
+	w.Flush()
+	if err := w.Write([]string{"a"}); err != ErrChecksumWritten {
+		t.Error("Expected ErrChecksumWritten, got:", err)
+	}
+}
+
+func TestUnknownChecksum(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewChecksummedWriter(new(bytes.Buffer), Dialect{}, "md4"); err != ErrUnknownChecksum {
+		t.Error("Expected ErrUnknownChecksum, got:", err)
+	}
+
+	r := NewReader(strings.NewReader("a b\n"))
+	r.ExpectChecksum = "md4"
+	if _, err := r.Read(); err != ErrUnknownChecksum {
+		t.Error("Expected ErrUnknownChecksum, got:", err)
+	}
+}
+
+func TestChecksumRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, algo := range []string{ChecksumCRC32, ChecksumSHA256} {
+		r := NewReader(writeChecksummed(t, algo))
+		r.ExpectChecksum = algo
+		data, err := r.ReadAll()
+		if err != nil {
+			t.Error("Unexpected error:", algo, err)
+		}
+		if !reflect.DeepEqual(data, checksumRecords) {
+			t.Error("Unexpected output:", algo, data)
+		}
+	}
+}
+
+func TestChecksumReadPositions(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(writeChecksummed(t, ChecksumCRC32))
+	r.ExpectChecksum = ChecksumCRC32
+	if record, err := r.Read(); err != nil || !reflect.DeepEqual(record, checksumRecords[0]) {
+		t.Error("Unexpected record:", record, err)
+	}
+	record, spans, err := r.ReadPositions()
+	if err != nil || !reflect.DeepEqual(record, checksumRecords[1]) {
+		t.Error("Unexpected record:", record, err)
+	}
+	if !reflect.DeepEqual(spans, [][2]int{{0, 1}, {2, 8}, {9, 10}}) {
+		t.Error("Unexpected spans:", spans)
+	}
+	if record, _, err := r.ReadPositions(); err != io.EOF {
+		t.Error("Expected trailer to be verified and stripped:", record, err)
+	}
+}
+
+func TestChecksumMaxParseColumn(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(writeChecksummed(t, ChecksumSHA256))
+	r.ExpectChecksum = ChecksumSHA256
+	r.MaxParseColumn = 1
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(data, [][]string{{"a"}, {"e"}}) {
+		t.Error("Unexpected output:", data)
+	}
+}
+
+func TestChecksumCorrupted(t *testing.T) {
+	t.Parallel()
+
+	b := writeChecksummed(t, ChecksumSHA256)
+	corrupted := b.Bytes()
+	corrupted[0] = 'x'
+
+	r := NewReader(bytes.NewReader(corrupted))
+	r.ExpectChecksum = ChecksumSHA256
+	if _, err := r.ReadAll(); err != ErrChecksumMismatch {
+		t.Error("Expected ErrChecksumMismatch, got:", err)
+	}
+}
+
+func TestChecksumTruncated(t *testing.T) {
+	t.Parallel()
+
+	b := writeChecksummed(t, ChecksumCRC32)
+	truncated := b.String()[:strings.Index(b.String(), ChecksumField)]
+
+	r := NewReader(strings.NewReader(truncated))
+	r.ExpectChecksum = ChecksumCRC32
+	if _, err := r.ReadAll(); err != ErrChecksumMissing {
+		t.Error("Expected ErrChecksumMissing, got:", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// A CountingWriter groups records and writes each group once, followed by a
+// field holding the number of records in the group. Records are buffered
+// until Flush, which writes the groups in the order they were first seen.
+//
+// Can be created by calling NewCountingWriter.
+type CountingWriter struct {
+	Writer
+	keyCols []int
+
+	groups map[string]int
+	rows   [][]string
+	counts []int
+}
+
+// Create a counting writer. Records are grouped by the fields at the indices
+// in keyCols, or by all fields if keyCols is empty. Each group is written as
+// the first record seen in it.
+func NewCountingWriter(w io.Writer, opts Dialect, keyCols []int) *CountingWriter {
+	return &CountingWriter{
+		Writer:  NewDialectWriter(w, opts),
+		keyCols: keyCols,
+		groups:  make(map[string]int),
+	}
+}
+
+// groupKey builds a key unique to the grouping fields of record.
+func (w *CountingWriter) groupKey(record []string) string {
+	var key bytes.Buffer
+	writeField := func(field string) {
+		key.WriteString(strconv.Itoa(len(field)))
+		key.WriteByte(':')
+		key.WriteString(field)
+	}
+	if len(w.keyCols) == 0 {
+		for _, field := range record {
+			writeField(field)
+		}
+		return key.String()
+	}
+	for _, col := range w.keyCols {
+		if col < len(record) {
+			writeField(record[col])
+		} else {
+			// Distinguishes a missing field from an empty one.
+			key.WriteByte('-')
+		}
+	}
+	return key.String()
+}
+
+// Write adds a record to its group. Nothing is written until Flush.
+func (w *CountingWriter) Write(record []string) error {
+	key := w.groupKey(record)
+	if n, ok := w.groups[key]; ok {
+		w.counts[n]++
+		return nil
+	}
+	w.groups[key] = len(w.rows)
+	w.rows = append(w.rows, append([]string(nil), record...))
+	w.counts = append(w.counts, 1)
+	return nil
+}
+
+// Flush writes every group seen since the previous Flush with its count and
+// flushes the underlying io.Writer. To check if an error occurred during the
+// Flush, call Error.
+func (w *CountingWriter) Flush() {
+	for n, row := range w.rows {
+		if err := w.Writer.Write(append(row, strconv.Itoa(w.counts[n]))); err != nil {
+			break
+		}
+	}
+	w.groups = make(map[string]int)
+	w.rows = nil
+	w.counts = nil
+	w.Writer.Flush()
+}
+
+// WriteAll adds multiple records using Write and then calls Flush.
+func (w *CountingWriter) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
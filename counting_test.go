@@ -0,0 +1,69 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCountingWriter(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	w := NewCountingWriter(b, Dialect{Delimiter: ','}, nil)
+	err := w.WriteAll([][]string{
+		{"b", "2"},
+		{"a", "1"},
+		{"b", "2"},
+		{"b", "3"},
+		{"b", "2"},
+		{"a", "1"},
+	})
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if s := b.String(); s != "b,2,3\na,1,2\nb,3,1\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
+
+func TestCountingWriterKeyColumns(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	w := NewCountingWriter(b, Dialect{Delimiter: ','}, []int{1})
+	w.Write([]string{"x", "LA", "1"})
+	w.Write([]string{"y", "SF", "2"})
+	w.Write([]string{"z", "LA", "3"})
+	w.Write([]string{"w"})
+	w.Write([]string{"v", ""})
+	if b.Len() != 0 {
+		t.Errorf("Expected nothing to be written before Flush: %q", b.String())
+	}
+	w.Flush()
+	if s := b.String(); s != "x,LA,1,2\ny,SF,2,1\nw,1\nv,,1\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+
+	b.Reset()
+	w.Write([]string{"u", "LA", "4"})
+	w.Flush()
+	if s := b.String(); s != "u,LA,4,1\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
+
+func TestCountingWriterAmbiguousKeys(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	w := NewCountingWriter(b, Dialect{Delimiter: ','}, nil)
+	w.Write([]string{"a,b", "c"})
+	w.Write([]string{"a", "b,c"})
+	w.Flush()
+	if s := b.String(); s != "\"a,b\",c,1\na,\"b,c\",1\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
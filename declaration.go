@@ -0,0 +1,163 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Prefix of a dialect declaration. A declaration is a comment on the first
+// line of a file describing its dialect, like
+//
+//	#csv delimiter=; quote=" escape=\\ doublequote=true quoting=minimal lineterminator=\n
+//
+// Values use Go escape sequences for backslashes, whitespace and
+// non-printable characters.
+const DeclarationPrefix = "#csv"
+
+var quotingNames = map[int]string{
+	QuoteAll:        "all",
+	QuoteMinimal:    "minimal",
+	QuoteNonNumeric: "nonnumeric",
+	QuoteNone:       "none",
+}
+
+func encodeDeclarationValue(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = quoted[1 : len(quoted)-1]
+	quoted = strings.Replace(quoted, "\\\"", "\"", -1)
+	return strings.Replace(quoted, " ", "\\x20", -1)
+}
+
+func decodeDeclarationValue(s string) (string, bool) {
+	value, err := strconv.Unquote("\"" + strings.Replace(s, "\"", "\\\"", -1) + "\"")
+	return value, err == nil
+}
+
+func decodeDeclarationRune(s string) (rune, bool) {
+	value, ok := decodeDeclarationValue(s)
+	if !ok || utf8.RuneCountInString(value) != 1 {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, true
+}
+
+// FormatDeclaration returns the declaration of the dialect, without a line
+// terminator. See DeclarationPrefix.
+func FormatDeclaration(opts Dialect) string {
+	opts.setDefaults()
+	return strings.Join([]string{
+		DeclarationPrefix,
+		"delimiter=" + encodeDeclarationValue(string(opts.Delimiter)),
+		"quote=" + encodeDeclarationValue(string(opts.QuoteChar)),
+		"escape=" + encodeDeclarationValue(string(opts.EscapeChar)),
+		"doublequote=" + strconv.FormatBool(opts.DoubleQuote == DoDoubleQuote),
+		"quoting=" + quotingNames[opts.Quoting],
+		"lineterminator=" + encodeDeclarationValue(opts.LineTerminator),
+	}, " ")
+}
+
+// NewDeclaredReader creates a reader honoring the dialect declared on the
+// first line of r, as written by Writer.WriteDeclaration. The declaration is
+// consumed so that reading starts at the first record. If there is no
+// declaration, fallback is used and nothing is consumed. Reports whether a
+// declaration was found.
+//
+// The declaration line must be terminated by a line terminator starting with
+// '\n' or '\r'.
+func NewDeclaredReader(r io.Reader, fallback Dialect) (*Reader, bool) {
+	reader := NewDialectReader(r, fallback)
+	if ok, _ := reader.r.NextIsString(DeclarationPrefix); !ok {
+		return reader, false
+	}
+
+	var line bytes.Buffer
+	for {
+		char, _, err := reader.r.ReadRune()
+		if err != nil {
+			break
+		}
+		if char == '\n' || char == '\r' {
+			reader.r.UnreadRune(char)
+			break
+		}
+		line.WriteRune(char)
+	}
+	dialect, ok := ParseDeclaration(line.String())
+	if !ok {
+		reader.r.UnreadString(line.String())
+		return reader, false
+	}
+
+	declared := newDialectReader(reader.r, *dialect)
+	for _, lineTerminator := range []string{dialect.LineTerminator, "\r\n", "\n", "\r"} {
+		if ok, _ := declared.r.NextIsString(lineTerminator); ok {
+			for _ = range lineTerminator {
+				declared.r.ReadRune()
+			}
+			break
+		}
+	}
+	// Lines are counted using the declared line terminator from now on.
+	declared.r.lines = 1
+	return declared, true
+}
+
+// ParseDeclaration parses a dialect declaration as written by
+// FormatDeclaration. Settings missing from the declaration keep their
+// defaults and unknown settings are ignored. Returns false if line is not a
+// valid declaration.
+func ParseDeclaration(line string) (*Dialect, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != DeclarationPrefix {
+		return nil, false
+	}
+
+	dialect := Dialect{}
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, false
+		}
+		key, value := parts[0], parts[1]
+
+		ok := true
+		switch key {
+		case "delimiter":
+			dialect.Delimiter, ok = decodeDeclarationRune(value)
+		case "quote":
+			dialect.QuoteChar, ok = decodeDeclarationRune(value)
+		case "escape":
+			dialect.EscapeChar, ok = decodeDeclarationRune(value)
+		case "lineterminator":
+			dialect.LineTerminator, ok = decodeDeclarationValue(value)
+		case "doublequote":
+			doubleQuote, err := strconv.ParseBool(value)
+			ok = err == nil
+			if doubleQuote {
+				dialect.DoubleQuote = DoDoubleQuote
+			} else {
+				dialect.DoubleQuote = NoDoubleQuote
+			}
+		case "quoting":
+			ok = false
+			for quoting, name := range quotingNames {
+				if name == value {
+					dialect.Quoting, ok = quoting, true
+				}
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	dialect.setDefaults()
+	return &dialect, true
+}
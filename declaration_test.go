@@ -0,0 +1,166 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatDeclaration(t *testing.T) {
+	t.Parallel()
+
+	expected := "#csv delimiter=\\x20 quote=\" escape=\\\\ doublequote=true quoting=minimal lineterminator=\\n"
+	if s := FormatDeclaration(Dialect{}); s != expected {
+		t.Error("Unexpected declaration:", s)
+	}
+
+	dialect := Dialect{
+		Delimiter:      '\t',
+		QuoteChar:      '\'',
+		DoubleQuote:    NoDoubleQuote,
+		Quoting:        QuoteNonNumeric,
+		LineTerminator: "\r\n",
+	}
+	expected = "#csv delimiter=\\t quote=' escape=\\\\ doublequote=false quoting=nonnumeric lineterminator=\\r\\n"
+	if s := FormatDeclaration(dialect); s != expected {
+		t.Error("Unexpected declaration:", s)
+	}
+}
+
+func TestParseDeclaration(t *testing.T) {
+	t.Parallel()
+
+	dialect, ok := ParseDeclaration("#csv delimiter=; quote=\"")
+	if !ok {
+		t.Fatal("Expected declaration to be parsed.")
+	}
+	expected := Dialect{Delimiter: ';', QuoteChar: '"'}
+	expected.setDefaults()
+	if *dialect != expected {
+		t.Error("Unexpected dialect:", *dialect)
+	}
+
+	dialect, ok = ParseDeclaration("#csv delimiter=\\x20 escape=\\\\ doublequote=false quoting=all lineterminator=\\r\\n unknown=1")
+	if !ok {
+		t.Fatal("Expected declaration to be parsed.")
+	}
+	expected = Dialect{Delimiter: ' ', EscapeChar: '\\', DoubleQuote: NoDoubleQuote, Quoting: QuoteAll, LineTerminator: "\r\n"}
+	expected.setDefaults()
+	if *dialect != expected {
+		t.Error("Unexpected dialect:", *dialect)
+	}
+
+	invalid := []string{
+		"",
+		"a,b,c",
+		"#csvdelimiter=;",
+		"# delimiter=;",
+		"#csv delimiter",
+		"#csv delimiter=;;",
+		"#csv quote=",
+		"#csv doublequote=maybe",
+		"#csv quoting=sometimes",
+	}
+	for _, line := range invalid {
+		if _, ok := ParseDeclaration(line); ok {
+			t.Error("Expected declaration to be invalid:", line)
+		}
+	}
+}
+
+func TestWriteDeclaration(t *testing.T) {
+	t.Parallel()
+
+	dialect := Dialect{
+		Delimiter:      ';',
+		QuoteChar:      '\'',
+		EscapeChar:     '"',
+		DoubleQuote:    NoDoubleQuote,
+		Quoting:        QuoteAll,
+		LineTerminator: "\r\n",
+	}
+	b := new(bytes.Buffer)
+	w := NewDialectWriter(b, dialect)
+	if err := w.WriteDeclaration(); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	w.Write([]string{"a", "b"})
+	w.Flush()
+
+	line, err := b.ReadString('\n')
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	parsed, ok := ParseDeclaration(line[:len(line)-2])
+	if !ok {
+		t.Fatal("Expected declaration to be parsed:", line)
+	}
+	dialect.setDefaults()
+	if !reflect.DeepEqual(*parsed, dialect) {
+		t.Error("Unexpected dialect:", *parsed)
+	}
+	if s := b.String(); s != "'a';'b'\r\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
+
+func TestNewDeclaredReader(t *testing.T) {
+	t.Parallel()
+
+	dialect := Dialect{
+		Delimiter:      ';',
+		QuoteChar:      '\'',
+		DoubleQuote:    NoDoubleQuote,
+		LineTerminator: "\r\n",
+	}
+	records := [][]string{
+		{"id", "name"},
+		{"1", "O'Brien"},
+		{"2", "a;b"},
+	}
+	b := new(bytes.Buffer)
+	w := NewDialectWriter(b, dialect)
+	if err := w.WriteDeclaration(); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	w.WriteAll(records)
+
+	r, ok := NewDeclaredReader(b, Dialect{})
+	if !ok {
+		t.Fatal("Expected declaration to be found.")
+	}
+	if r.opts.Delimiter != ';' || r.opts.LineTerminator != "\r\n" {
+		t.Error("Unexpected dialect:", r.opts)
+	}
+	read, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(read, records) {
+		t.Error("Unexpected records:", read)
+	}
+}
+
+func TestNewDeclaredReaderMissing(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"#a,b\nc,d\n", "#csv delimiter=;;\nc,d\n"} {
+		r, ok := NewDeclaredReader(strings.NewReader(input), Dialect{Delimiter: ','})
+		if ok {
+			t.Error("Unexpected declaration:", input)
+		}
+		records, err := r.ReadAll()
+		if err != nil {
+			t.Error("Unexpected error:", err)
+		}
+		first := strings.Split(strings.SplitN(input, "\n", 2)[0], ",")
+		expected := [][]string{first, {"c", "d"}}
+		if !reflect.DeepEqual(records, expected) {
+			t.Error("Unexpected records:", records)
+		}
+	}
+}
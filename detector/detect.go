@@ -6,6 +6,9 @@ import (
 	"io"
 	"math"
 	"regexp"
+	"unicode"
+
+	csv "github.com/bcmcmill/go-csv"
 )
 
 const (
@@ -24,6 +27,17 @@ func New() Detector {
 type Detector interface {
 	DetectDelimiter(reader io.Reader, enclosure byte) []string
 	DetectRowTerminator(reader io.Reader) string
+	DetectMultilineColumns(reader io.Reader, dialect csv.Dialect) []int
+	DetectEnclosure(reader io.Reader, delimiter byte) (best, runnerUp EnclosureCandidate)
+	DetectFieldPadding(reader io.Reader, delimiter, enclosure byte) []int
+	DetectDeclaredDialect(reader *bufio.Reader) (*csv.Dialect, bool)
+}
+
+// EnclosureCandidate is a possible enclosure together with its score. The
+// higher the score, the more the character behaves like an enclosure.
+type EnclosureCandidate struct {
+	Enclosure byte
+	Score     int
 }
 
 // detector is the default implementation of Detector.
@@ -53,6 +67,211 @@ func (d *detector) DetectRowTerminator(reader io.Reader) string {
 	return "\n"
 }
 
+// DetectMultilineColumns finds the indices of the columns that contain
+// enclosed line terminators within the sampled lines. Only ASCII delimiters,
+// quote characters and escape characters are supported; nil is returned for
+// any other dialect.
+func (d *detector) DetectMultilineColumns(reader io.Reader, dialect csv.Dialect) []int {
+	delimiter, enclosure, escape := rune(csv.DefaultDelimiter), rune(csv.DefaultQuoteChar), rune(0)
+	if dialect.Delimiter != 0 {
+		delimiter = dialect.Delimiter
+	}
+	if dialect.QuoteChar != 0 {
+		enclosure = dialect.QuoteChar
+	}
+	if dialect.DoubleQuote == csv.NoDoubleQuote {
+		escape = csv.DefaultEscapeChar
+		if dialect.EscapeChar != 0 {
+			escape = dialect.EscapeChar
+		}
+	}
+	if delimiter > unicode.MaxASCII || enclosure > unicode.MaxASCII || escape > unicode.MaxASCII {
+		return nil
+	}
+
+	var multiline []bool
+	column := 0
+	scan(reader, sampleLines, byte(enclosure), byte(escape), func(current byte, enclosed bool) {
+		switch {
+		case current == '\n' || current == '\r':
+			if !enclosed {
+				column = 0
+				return
+			}
+			for len(multiline) <= column {
+				multiline = append(multiline, false)
+			}
+			multiline[column] = true
+		case current == byte(delimiter) && !enclosed:
+			column++
+		}
+	})
+
+	var columns []int
+	for column, ok := range multiline {
+		if ok {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// possibleEnclosures are the enclosures considered by DetectEnclosure, in order
+// of preference when tied.
+var possibleEnclosures = []byte{'"', '\''}
+
+// DetectEnclosure finds the enclosure used with delimiter. Every candidate
+// gains a point each time it appears next to a delimiter or line boundary,
+// where an enclosure opens or closes a field, and loses a point each time it
+// appears in the middle of a field, like an apostrophe in prose. Doubled
+// candidates are escaped enclosures or empty fields and are not scored. The
+// runner-up is returned for inspection.
+func (d *detector) DetectEnclosure(reader io.Reader, delimiter byte) (best, runnerUp EnclosureCandidate) {
+	bufferedReader := bufio.NewReader(reader)
+	var sample []byte
+	for line := 0; line < sampleLines; line++ {
+		chunk, err := bufferedReader.ReadBytes('\n')
+		sample = append(sample, chunk...)
+		if err != nil {
+			break
+		}
+	}
+
+	isBoundary := func(i int) bool {
+		if i < 0 || i >= len(sample) {
+			return true
+		}
+		char := sample[i]
+		return char == delimiter || char == '\n' || char == '\r'
+	}
+
+	candidates := make([]EnclosureCandidate, len(possibleEnclosures))
+	for n, enclosure := range possibleEnclosures {
+		candidates[n].Enclosure = enclosure
+		for i := 0; i < len(sample); i++ {
+			if sample[i] != enclosure {
+				continue
+			}
+			if i+1 < len(sample) && sample[i+1] == enclosure {
+				i++
+				continue
+			}
+			if isBoundary(i-1) || isBoundary(i+1) {
+				candidates[n].Score++
+			} else {
+				candidates[n].Score--
+			}
+		}
+	}
+
+	best, runnerUp = candidates[0], candidates[1]
+	if runnerUp.Score > best.Score {
+		best, runnerUp = runnerUp, best
+	}
+	return
+}
+
+// DetectFieldPadding finds the width of each column of files that pad every
+// field to a fixed width with spaces between the delimiters. Widths are in
+// bytes, including any enclosures. Returns nil unless every column has the
+// same width on all sampled lines and at least one field is padded. Such
+// files can be read using csv.Reader.TrimFieldPadding.
+func (d *detector) DetectFieldPadding(reader io.Reader, delimiter, enclosure byte) []int {
+	bufferedReader := bufio.NewReader(reader)
+	var widths []int
+	padded := false
+	lines := 0
+
+	for lines < sampleLines {
+		line, err := bufferedReader.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			var lineWidths []int
+			enclosed := false
+			start := 0
+			for i := 0; i <= len(line); i++ {
+				if i < len(line) && line[i] == enclosure {
+					enclosed = !enclosed
+				}
+				if i < len(line) && (line[i] != delimiter || enclosed) {
+					continue
+				}
+				field := line[start:i]
+				if len(field) > 0 && (field[0] == ' ' || field[len(field)-1] == ' ') {
+					padded = true
+				}
+				lineWidths = append(lineWidths, len(field))
+				start = i + 1
+			}
+
+			if widths == nil {
+				widths = lineWidths
+			} else if !equalWidths(widths, lineWidths) {
+				return nil
+			}
+			lines++
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if lines < 2 || !padded {
+		return nil
+	}
+	return widths
+}
+
+func equalWidths(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DetectDeclaredDialect parses the dialect declared by a comment on the first
+// line, as written by csv.Writer.WriteDeclaration. Returns false if there is
+// no such declaration, in which case the other detection methods can be used.
+//
+// Only a declaration and its line terminator are consumed, so the records
+// can be read from reader afterwards. Nothing is consumed if there is no
+// declaration.
+func (d *detector) DetectDeclaredDialect(reader *bufio.Reader) (*csv.Dialect, bool) {
+	var line []byte
+	for n := len(csv.DeclarationPrefix); ; n++ {
+		peeked, err := reader.Peek(n)
+		if err == io.EOF {
+			line = peeked
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		if last := peeked[n-1]; last == '\n' || last == '\r' {
+			line = peeked[:n-1]
+			break
+		}
+	}
+	dialect, ok := csv.ParseDeclaration(string(line))
+	if !ok {
+		return nil, false
+	}
+	reader.Discard(len(line))
+
+	for _, lineTerminator := range []string{dialect.LineTerminator, "\r\n", "\n", "\r"} {
+		if next, _ := reader.Peek(len(lineTerminator)); string(next) == lineTerminator {
+			reader.Discard(len(lineTerminator))
+			break
+		}
+	}
+	return dialect, true
+}
+
 // validDelimiter tests a byte to verify it is one of the possible valid delimiters.
 func validDelimiter(char byte) bool {
 	var possibleDelimiters = []byte{',', '|', '\t', ';'}
@@ -138,6 +357,47 @@ func (d *detector) sample(reader io.Reader, sampleLines int, enclosure byte) (fr
 	return
 }
 
+// scan walks through the characters of up to sampleLines lines, keeping track
+// of whether they are enclosed, and hands every character except enclosures
+// to visit. Doubled enclosures within an enclosure are escaped ones, as are
+// characters following escape unless it is 0.
+func scan(reader io.Reader, sampleLines int, enclosure, escape byte, visit func(current byte, enclosed bool)) {
+	bufferedReader := bufio.NewReader(reader)
+	enclosed := false
+	line := 1
+	var prev byte
+
+	for {
+		current, err := bufferedReader.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case enclosed && escape != 0 && current == escape:
+			// Skipping the escaped character.
+			if current, err = bufferedReader.ReadByte(); err != nil {
+				return
+			}
+		case current == enclosure:
+			if next, _ := bufferedReader.Peek(1); enclosed && len(next) == 1 && next[0] == enclosure {
+				current, _ = bufferedReader.ReadByte()
+			} else {
+				enclosed = !enclosed
+			}
+		default:
+			visit(current, enclosed)
+			if !enclosed && (current == '\n' && prev != '\r' || current == '\r') {
+				line++
+				if line > sampleLines {
+					return
+				}
+			}
+		}
+		prev = current
+	}
+}
+
 // analyze is built based on such an observation: the delimiter must appears
 // the same number of times at each line, usually, it appears more than once.
 // Therefore for each delimiter candidate, the deviation of its frequency at
@@ -1,6 +1,8 @@
 package detector
 
 import (
+	"bufio"
+	"bytes"
 	"os"
 	"regexp"
 	"testing"
@@ -8,6 +10,8 @@ import (
 	"fmt"
 
 	"github.com/stretchr/testify/assert"
+
+	csv "github.com/bcmcmill/go-csv"
 )
 
 func TestIsPotentialDelimiter(t *testing.T) {
@@ -90,6 +94,141 @@ func TestDetectRowTerminator(t *testing.T) {
 	assert.Equal(t, "\n", terminator)
 }
 
+func TestDetectMultilineColumns(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/multiline.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	columns := detector.DetectMultilineColumns(file, csv.Dialect{Delimiter: ','})
+	assert.Equal(t, []int{1}, columns)
+}
+
+func TestDetectMultilineColumnsNone(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/test2.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	columns := detector.DetectMultilineColumns(file, csv.Dialect{Delimiter: ','})
+	assert.Equal(t, []int(nil), columns)
+}
+
+func TestDetectMultilineColumnsNonASCII(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/multiline.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	columns := detector.DetectMultilineColumns(file, csv.Dialect{Delimiter: '¦'})
+	assert.Equal(t, []int(nil), columns)
+}
+
+func TestDetectEnclosure(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/apostrophes.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	best, runnerUp := detector.DetectEnclosure(file, ',')
+	assert.Equal(t, EnclosureCandidate{'"', 10}, best)
+	assert.Equal(t, byte('\''), runnerUp.Enclosure)
+	assert.True(t, runnerUp.Score < 0)
+}
+
+func TestDetectEnclosureNoQuotes(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/test2.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	best, runnerUp := detector.DetectEnclosure(file, ',')
+	assert.Equal(t, EnclosureCandidate{'"', 0}, best)
+	assert.Equal(t, EnclosureCandidate{'\'', 0}, runnerUp)
+}
+
+func TestDetectFieldPadding(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/padded.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	widths := detector.DetectFieldPadding(file, '|', '"')
+	assert.Equal(t, []int{4, 10, 12, 8}, widths)
+}
+
+func TestDetectFieldPaddingUnpadded(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/test2.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	widths := detector.DetectFieldPadding(file, ',', '"')
+	assert.Equal(t, []int(nil), widths)
+}
+
+func TestDetectDeclaredDialect(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/declared.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	dialect, ok := detector.DetectDeclaredDialect(reader)
+	assert.True(t, ok)
+	assert.Equal(t, ';', dialect.Delimiter)
+	assert.Equal(t, '\'', dialect.QuoteChar)
+	assert.Equal(t, csv.NoDoubleQuote, dialect.DoubleQuote)
+	assert.Equal(t, "\r\n", dialect.LineTerminator)
+
+	records, err := csv.NewDialectReader(reader, *dialect).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"id", "name"}, {"1", "O'Brien"}}, records)
+
+	// Declarations terminated by a carriage return only.
+	b := new(bytes.Buffer)
+	w := csv.NewDialectWriter(b, csv.Dialect{Delimiter: '|', LineTerminator: "\r"})
+	assert.NoError(t, w.WriteDeclaration())
+	w.Write([]string{"a", "b"})
+	w.Flush()
+
+	reader = bufio.NewReader(b)
+	dialect, ok = detector.DetectDeclaredDialect(reader)
+	assert.True(t, ok)
+	assert.Equal(t, '|', dialect.Delimiter)
+	assert.Equal(t, "\r", dialect.LineTerminator)
+
+	records, err = csv.NewDialectReader(reader, *dialect).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}}, records)
+}
+
+func TestDetectDeclaredDialectMissing(t *testing.T) {
+	detector := New()
+
+	file, err := os.OpenFile("./Fixtures/test1.csv", os.O_RDONLY, os.ModePerm)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	dialect, ok := detector.DetectDeclaredDialect(reader)
+	assert.False(t, ok)
+	assert.Nil(t, dialect)
+
+	// Nothing is consumed.
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "Year,Make,Model,Description,Price\n", line)
+}
+
 func TestDetectorSample(t *testing.T) {
 	detector := New().(*detector)
 
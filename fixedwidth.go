@@ -0,0 +1,121 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Alignment of a value within its fixed-width column.
+type Alignment int
+
+// Values Alignment can take.
+const (
+	AlignLeft  Alignment = iota // Pad with trailing spaces.
+	AlignRight Alignment = iota // Pad with leading spaces.
+)
+
+var (
+	ErrFieldTooWide  = errors.New("Field is wider than its column.")
+	ErrWrongFieldNum = errors.New("Record does not have one field per column.")
+)
+
+// A FixedWidthWriter writes records as space-padded fixed-width rows instead of
+// delimited ones. Widths are counted in runes.
+//
+// Can be created by calling NewFixedWidthWriter.
+type FixedWidthWriter struct {
+	// If set, fields wider than their column are truncated. Otherwise Write
+	// fails with ErrFieldTooWide.
+	Truncate bool
+	// String that separates each row. Defaults to DefaultLineTerminator.
+	LineTerminator string
+
+	widths []int
+	align  []Alignment
+	w      *bufio.Writer
+}
+
+// Create a fixed-width writer with one column per width. align holds the
+// alignment of each column; columns without one are left aligned.
+func NewFixedWidthWriter(w io.Writer, widths []int, align []Alignment) *FixedWidthWriter {
+	return &FixedWidthWriter{
+		LineTerminator: DefaultLineTerminator,
+		widths:         widths,
+		align:          align,
+		w:              bufio.NewWriter(w),
+	}
+}
+
+// Error reports any error that has occurred during a previous Write or Flush.
+func (w *FixedWidthWriter) Error() error {
+	_, err := w.w.Write(nil)
+	return err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+// To check if an error occurred during the Flush, call Error.
+func (w *FixedWidthWriter) Flush() {
+	w.w.Flush()
+}
+
+// Write writes a single record to w as a fixed-width row. The record must
+// have one field per column. Nothing is written if it fails.
+func (w *FixedWidthWriter) Write(record []string) error {
+	if len(record) != len(w.widths) {
+		return ErrWrongFieldNum
+	}
+
+	var row bytes.Buffer
+	for n, field := range record {
+		width := w.widths[n]
+		length := utf8.RuneCountInString(field)
+		if length > width {
+			if !w.Truncate {
+				return ErrFieldTooWide
+			}
+			field = truncateRunes(field, width)
+			length = width
+		}
+
+		padding := strings.Repeat(" ", width-length)
+		if n < len(w.align) && w.align[n] == AlignRight {
+			row.WriteString(padding)
+			row.WriteString(field)
+		} else {
+			row.WriteString(field)
+			row.WriteString(padding)
+		}
+	}
+	row.WriteString(w.LineTerminator)
+
+	_, err := row.WriteTo(w.w)
+	return err
+}
+
+// WriteAll writes multiple records to w using Write and then calls Flush.
+func (w *FixedWidthWriter) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// truncateRunes returns the first n runes of s.
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFixedWidthPadding(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	w := NewFixedWidthWriter(b, []int{5, 3, 4}, nil)
+	w.Write([]string{"ab", "c", "defg"})
+	w.Write([]string{"", "åäö", "h"})
+	w.Flush()
+	if s := b.String(); s != "ab   c  defg\n     åäöh   \n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
+
+func TestFixedWidthAlignment(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	w := NewFixedWidthWriter(b, []int{4, 6, 3}, []Alignment{AlignLeft, AlignRight})
+	w.LineTerminator = "\r\n"
+	if err := w.WriteAll([][]string{{"a", "12.50", "b"}, {"cd", "3", "e"}}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if s := b.String(); s != "a    12.50b  \r\ncd       3e  \r\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
+
+func TestFixedWidthTooWide(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	w := NewFixedWidthWriter(b, []int{3, 3}, []Alignment{AlignLeft, AlignRight})
+	if err := w.Write([]string{"abcd", "e"}); err != ErrFieldTooWide {
+		t.Error("Expected ErrFieldTooWide, got:", err)
+	}
+	w.Flush()
+	if b.Len() != 0 {
+		t.Errorf("Expected nothing to be written: %q", b.String())
+	}
+
+	w.Truncate = true
+	if err := w.Write([]string{"abcd", "åäöü"}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	w.Flush()
+	if s := b.String(); s != "abcåäö\n" {
+		t.Errorf("Unexpected output: %q", s)
+	}
+}
+
+func TestFixedWidthFieldCount(t *testing.T) {
+	t.Parallel()
+
+	w := NewFixedWidthWriter(new(bytes.Buffer), []int{3, 3}, nil)
+	if err := w.Write([]string{"a"}); err != ErrWrongFieldNum {
+		t.Error("Expected ErrWrongFieldNum, got:", err)
+	}
+}
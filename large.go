@@ -0,0 +1,188 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// fieldBuffer accumulates the runes of a single field. Once it holds more than
+// threshold bytes its content is moved to a temporary file in dir, which is
+// closed by finish. A zero threshold keeps everything in memory.
+type fieldBuffer struct {
+	threshold int
+	dir       string
+
+	mem  bytes.Buffer
+	file *os.File
+	fw   *bufio.Writer
+	path string
+	size int64
+	err  error
+
+	// Offsets of the raw field within the stream.
+	start, end int64
+	// Whether a spilled field still needs to be base64 decoded.
+	base64 bool
+	// Called when the field is spilled.
+	onSpill func()
+}
+
+func (s *fieldBuffer) WriteRune(r rune) {
+	if s.err != nil {
+		return
+	}
+	if s.file != nil {
+		n, err := s.fw.WriteRune(r)
+		s.size += int64(n)
+		s.err = err
+		return
+	}
+	n, _ := s.mem.WriteRune(r)
+	s.size += int64(n)
+	if s.threshold > 0 && s.mem.Len() > s.threshold {
+		s.spill()
+	}
+}
+
+func (s *fieldBuffer) spill() {
+	if s.file, s.err = ioutil.TempFile(s.dir, "csv-field-"); s.err != nil {
+		return
+	}
+	if s.onSpill != nil {
+		s.onSpill()
+	}
+	s.path = s.file.Name()
+	s.fw = bufio.NewWriter(s.file)
+	_, s.err = s.mem.WriteTo(s.fw)
+}
+
+// finish flushes and closes the file of a spilled field and returns any error
+// that occurred while spilling.
+func (s *fieldBuffer) finish() error {
+	if s.file == nil {
+		return s.err
+	}
+	if s.err == nil {
+		s.err = s.fw.Flush()
+	}
+	if err := s.file.Close(); s.err == nil {
+		s.err = err
+	}
+	s.file, s.fw = nil, nil
+	return s.err
+}
+
+// reset empties a field held in memory so that it can be reused.
+func (s *fieldBuffer) reset() {
+	s.mem.Reset()
+	s.size, s.base64 = 0, false
+}
+
+// discard removes the temporary file of a spilled field.
+func (s *fieldBuffer) discard() {
+	if s.path != "" {
+		os.Remove(s.path)
+	}
+}
+
+// String returns the field if it is held in memory.
+func (s *fieldBuffer) String() string {
+	return s.mem.String()
+}
+
+// A LargeField is a field returned by ReadLarge. Its value is either held in
+// memory or, if it was spilled, stored in a temporary file.
+type LargeField struct {
+	value  string
+	path   string
+	size   int64
+	base64 bool
+}
+
+// Spilled reports whether the field was spilled to a temporary file.
+func (f LargeField) Spilled() bool {
+	return f.path != ""
+}
+
+// Value returns the field if it is held in memory. It returns an empty string
+// for spilled fields; use Reader to read those.
+func (f LargeField) Value() string {
+	return f.value
+}
+
+// Len returns the size of the field in bytes. For spilled fields in
+// Reader.Base64Columns, this is the size before decoding.
+func (f LargeField) Len() int64 {
+	return f.size
+}
+
+// Reader returns a reader for the field's value, regardless of whether it was
+// spilled. Spilled fields are opened again from their temporary file, which
+// fails once the Reader that returned the field has been closed. The caller
+// must close the returned reader.
+func (f LargeField) Reader() (io.ReadCloser, error) {
+	if f.path == "" {
+		return ioutil.NopCloser(strings.NewReader(f.value)), nil
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.base64 {
+		return struct {
+			io.Reader
+			io.Closer
+		}{base64.NewDecoder(base64Encoding, file), file}, nil
+	}
+	return file, nil
+}
+
+// ReadLarge reads one record from r like Read. Fields larger than
+// r.SpillThreshold bytes are spilled to temporary files instead of being kept
+// in memory, which bounds the memory used for huge fields. Call Close to
+// remove the temporary files. Since spilled fields are not kept, their
+// checksum can not be verified; ReadLarge fails with ErrChecksumUnsupported if
+// ExpectChecksum is set.
+func (r *Reader) ReadLarge() ([]LargeField, error) {
+	if r.ExpectChecksum != "" {
+		return nil, ErrChecksumUnsupported
+	}
+	fields, err := r.readFields(true)
+	if fields == nil {
+		return nil, err
+	}
+	record := make([]LargeField, 0, len(fields))
+	for _, s := range fields {
+		if s.path != "" {
+			r.spilled = append(r.spilled, s.path)
+		}
+		record = append(record, LargeField{
+			value:  s.String(),
+			path:   s.path,
+			size:   s.size,
+			base64: s.base64,
+		})
+	}
+	return record, err
+}
+
+// Close removes all temporary files created by ReadLarge. It does not close
+// the underlying io.Reader.
+func (r *Reader) Close() error {
+	var firstErr error
+	for _, path := range r.spilled {
+		if err := os.Remove(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.spilled = nil
+	return firstErr
+}
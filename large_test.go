@@ -0,0 +1,180 @@
+// Copyright 2014 Jens Rantil. All rights reserved.  Use of this source code is
+// governed by a BSD-style license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLarge(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "csv-test-")
+	if err != nil {
+		t.Fatal("Could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	large := strings.Repeat("x y ", 25)
+	b := new(bytes.Buffer)
+	b.WriteString("a \"" + large + "\" c\nd e f\n")
+	r := NewReader(b)
+	r.SpillThreshold = 16
+	r.SpillDir = dir
+
+	record, err := r.ReadLarge()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(record) != 3 {
+		t.Fatal("Wrong number of fields:", len(record))
+	}
+	if record[0].Spilled() || record[0].Value() != "a" {
+		t.Error("Unexpected inline field:", record[0])
+	}
+	if !record[1].Spilled() || record[1].Value() != "" || record[1].Len() != int64(len(large)) {
+		t.Error("Expected field to be spilled:", record[1])
+	}
+	if data, err := readLargeField(record[1]); err != nil || data != large {
+		t.Error("Unexpected spilled field:", data, err)
+	}
+	if data, _ := readLargeField(record[2]); data != "c" {
+		t.Error("Unexpected inline field:", data)
+	}
+
+	if err := testReadingSingleLine(t, r, []string{"d", "e", "f"}); err != nil && err != io.EOF {
+		t.Error("Unexpected error:", err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Error("Temporary files were not removed:", len(files))
+	}
+	if _, err := record[1].Reader(); err == nil {
+		t.Error("Expected spilled field to be removed.")
+	}
+}
+
+func TestReadLargeOnError(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "csv-test-")
+	if err != nil {
+		t.Fatal("Could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	large := strings.Repeat("x", 1<<20)
+	b := new(bytes.Buffer)
+	b.WriteString("a \"" + large + "\"x b\n")
+	b.WriteString("c \"" + large + "\" d\n")
+	b.WriteString("e f\n")
+
+	var lines []int
+	r := NewReader(b)
+	r.SpillThreshold = 1024
+	r.SpillDir = dir
+	r.OnError = func(line int, raw []byte, err error) Action {
+		lines = append(lines, line)
+		if len(raw) > 4096 {
+			t.Error("Raw record was not cut short:", len(raw))
+		}
+		return Retry
+	}
+	defer r.Close()
+
+	record, err := r.ReadLarge()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if len(record) != 3 || record[0].Value() != "c" || !record[1].Spilled() || record[2].Value() != "d" {
+		t.Error("Unexpected record:", record)
+	}
+	if n := r.r.rec.Len(); n > 4096 {
+		t.Error("Raw record was not cut short:", n)
+	}
+	if !reflect.DeepEqual(lines, []int{1}) {
+		t.Error("Unexpected lines:", lines)
+	}
+
+	record, err = r.ReadLarge()
+	if err != nil || len(record) != 2 || record[0].Value() != "e" || record[1].Value() != "f" {
+		t.Error("Unexpected record:", record, err)
+	}
+}
+
+func readLargeField(f LargeField) (string, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	return string(data), err
+}
+
+func TestReadLargeManyFields(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "csv-test-")
+	if err != nil {
+		t.Fatal("Could not create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// More spilled fields than the common default limit of 1024 open files.
+	large := strings.Repeat("x", 32)
+	row := strings.Repeat(large+" ", 99) + large + "\n"
+	r := NewReader(strings.NewReader(strings.Repeat(row, 50)))
+	r.SpillThreshold = 16
+	r.SpillDir = dir
+	defer r.Close()
+
+	var last LargeField
+	for {
+		record, err := r.ReadLarge()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		last = record[len(record)-1]
+	}
+	if data, err := readLargeField(last); err != nil || data != large {
+		t.Error("Unexpected spilled field:", data, err)
+	}
+}
+
+func TestReadLargeWithoutThreshold(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("x", 4096)
+	r := NewReader(strings.NewReader(large + " b\n"))
+	record, err := r.ReadLarge()
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if record[0].Spilled() || record[0].Value() != large {
+		t.Error("Expected field to be held in memory.")
+	}
+}
+
+func TestReadLargeChecksum(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(writeChecksummed(t, ChecksumCRC32))
+	r.ExpectChecksum = ChecksumCRC32
+	if _, err := r.ReadLarge(); err != ErrChecksumUnsupported {
+		t.Error("Unexpected error:", err)
+	}
+}
@@ -6,6 +6,9 @@ package csv
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"strings"
 	"unicode/utf8"
@@ -15,6 +18,19 @@ import (
 type unReader struct {
 	r *bufio.Reader
 	b *bytes.Buffer
+
+	// If non-nil, every rune consumed is also written here. Unread runes are
+	// removed again.
+	rec *bytes.Buffer
+	// Whether recording has been paused, and the number of bytes consumed but
+	// not recorded since.
+	recPaused  bool
+	recSkipped int
+	// Number of bytes consumed.
+	offset int64
+	// Number of lineRune consumed, that is the number of lines passed.
+	lines    int
+	lineRune rune
 }
 
 func newUnreader(r io.Reader) *unReader {
@@ -24,17 +40,74 @@ func newUnreader(r io.Reader) *unReader {
 	}
 }
 
-func (u *unReader) ReadRune() (rune, int, error) {
+func (u *unReader) ReadRune() (r rune, size int, err error) {
 	if u.b.Len() > 0 {
-		return u.b.ReadRune()
+		r, size, err = u.b.ReadRune()
+	} else {
+		r, size, err = u.r.ReadRune()
+	}
+	if err != nil {
+		return
 	}
-	return u.r.ReadRune()
+	u.offset += int64(size)
+	if r == u.lineRune {
+		u.lines++
+	}
+	if u.rec != nil {
+		if u.recPaused {
+			u.recSkipped += utf8.RuneLen(r)
+		} else {
+			u.rec.WriteRune(r)
+		}
+	}
+	return
+}
+
+// pauseRecording stops writing consumed runes to rec until resumeRecording is
+// called.
+func (u *unReader) pauseRecording() {
+	u.recPaused = true
+}
+
+func (u *unReader) resumeRecording() {
+	u.recPaused = false
+	u.recSkipped = 0
 }
 
 func (u *unReader) UnreadRune(r rune) {
+	if u.rec != nil {
+		u.UnreadString(string(r))
+		return
+	}
+	// Runes are put back all the time, so without recording this avoids the
+	// bookkeeping of UnreadString.
+	u.offset -= int64(utf8.RuneLen(r))
+	if r == u.lineRune {
+		u.lines--
+	}
+	u.prepend(string(r))
+}
+
+// UnreadString puts back s, which must be the last consumed string.
+func (u *unReader) UnreadString(s string) {
+	u.offset -= int64(len(s))
+	u.lines -= strings.Count(s, string(u.lineRune))
+	if u.rec != nil {
+		// The most recent bytes are the ones that were not recorded.
+		recorded := len(s) - u.recSkipped
+		if recorded < 0 {
+			recorded = 0
+		}
+		u.recSkipped -= len(s) - recorded
+		u.rec.Truncate(u.rec.Len() - recorded)
+	}
+	u.prepend(s)
+}
+
+func (u *unReader) prepend(s string) {
 	// Poor man's prepend
 	var tmpBuf bytes.Buffer
-	tmpBuf.WriteRune(r)
+	tmpBuf.WriteString(s)
 	tmpBuf.ReadFrom(u.b)
 
 	u.b = &tmpBuf
@@ -52,12 +125,104 @@ func (u *unReader) NextIsString(s string) (bool, error) {
 	return strings.HasPrefix(u.b.String(), s), nil
 }
 
+// ErrQuote is returned, wrapped in a ParseError, when a quoted field is not
+// terminated or when its closing quote is followed by something else than a
+// delimiter or line terminator. Such records used to be returned cut short,
+// along with io.EOF or no error at all, and are now rejected even if OnError is
+// not set.
+var ErrQuote = errors.New("Extraneous or missing quote in quoted field.")
+
+// A ParseError is returned by Reader when a record is malformed.
+type ParseError struct {
+	Line   int   // Line where the record starts, counting from 1.
+	Column int   // Index of the field causing the error, counting from 0.
+	Err    error // The actual error.
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+// Action tells a Reader how to proceed after a malformed record. See
+// Reader.OnError.
+type Action int
+
+// Values Action can take.
+const (
+	// Return the error from the read call.
+	Abort Action = iota
+	// Drop the record and continue with the line following the error.
+	Skip Action = iota
+	// Drop the record and resume parsing at the line following the first line
+	// of the record. Useful when a stray quote made the record swallow the
+	// lines after it.
+	Retry Action = iota
+)
+
 // A Reader reads records from a CSV-encoded file.
 //
 // Can be created by calling either NewReader or using NewDialectReader.
 type Reader struct {
+	// If set, the stream is expected to end with a checksum trailer as written
+	// by a ChecksummedWriter using this algorithm. The trailer is verified and
+	// stripped; Read returns ErrChecksumMismatch or ErrChecksumMissing if
+	// verification fails.
+	ExpectChecksum string
+
+	// If set, called with the first field of every record to select the
+	// delimiter separating the remaining fields of that record. The first field
+	// itself is always terminated by the dialect's delimiter, so it must not
+	// contain that delimiter unless quoted. Returning 0 keeps the dialect's
+	// delimiter.
+	DelimiterFunc func(firstField string) rune
+
+	// Fields larger than this many bytes are spilled to temporary files by
+	// ReadLarge. Zero keeps all fields in memory.
+	SpillThreshold int
+	// Directory to spill fields to. Defaults to os.TempDir().
+	SpillDir string
+
+	// If set, called for every malformed record with the line it starts at and
+	// its raw bytes, including the rest of the line the error occurred on. The
+	// returned Action decides whether the error is returned or the record is
+	// dropped. Without OnError every parse error is returned.
+	//
+	// To keep memory bounded, raw bytes are no longer collected once ReadLarge
+	// spills a field of the record. raw is then cut short and Retry behaves
+	// like Skip.
+	OnError func(line int, raw []byte, err error) Action
+
+	// If set, spaces padding fields to a fixed width are removed. Spaces within
+	// quotes are kept. Has no effect if the delimiter is a space.
+	TrimFieldPadding bool
+
+	// If positive, only the first MaxParseColumn fields of every record are
+	// parsed and returned. The rest of the record is skipped without being
+	// split into fields, only looking for quotes to find where it ends. This
+	// assumes quote characters only appear around fields.
+	MaxParseColumn int
+
+	// Indices of columns holding base64 encoded values, as written by a Writer
+	// with the same Base64Columns. They are decoded when read. A field that
+	// can't be decoded results in a ParseError.
+	Base64Columns []int
+
 	opts Dialect
 	r    *unReader
+
+	// Delimiter of the record currently being read.
+	delimiter rune
+	// Offset of the record currently being read.
+	recordStart int64
+
+	checksum     hash.Hash
+	checksumDone bool
+
+	// Temporary files created by ReadLarge. Removed by Close.
+	spilled []string
+	// Fields reused by reads that don't spill, which turn them into strings
+	// before reading on.
+	buffers []*fieldBuffer
 }
 
 // Creates a reader that conforms to RFC 4180 and behaves identical as a
@@ -72,10 +237,15 @@ func NewReader(r io.Reader) *Reader {
 
 // Create a custom CSV reader.
 func NewDialectReader(r io.Reader, opts Dialect) *Reader {
+	return newDialectReader(newUnreader(r), opts)
+}
+
+func newDialectReader(u *unReader, opts Dialect) *Reader {
 	opts.setDefaults()
+	u.lineRune, _ = utf8.DecodeLastRuneInString(opts.LineTerminator)
 	return &Reader{
 		opts: opts,
-		r:    newUnreader(r),
+		r:    u,
 	}
 }
 
@@ -101,52 +271,208 @@ func (r *Reader) ReadAll() ([][]string, error) {
 }
 
 // Read reads one record from r. The record is a slice of strings with each
-// string representing one field.
+// string representing one field. A malformed record results in a ParseError
+// and a nil record, unless OnError decides to drop it. In particular, records
+// with misplaced quotes are not returned partially; see ErrQuote.
 func (r *Reader) Read() ([]string, error) {
+	fields, err := r.readRecord()
+	if fields == nil {
+		return nil, err
+	}
 	// TODO: Possible optimization; store the maximum number of columns for
 	// faster preallocation.
-	record := make([]string, 0, 2)
+	record := make([]string, 0, len(fields))
+	for _, s := range fields {
+		record = append(record, s.String())
+	}
+	return record, err
+}
+
+// readRecord reads the fields of one record, verifying the checksum if
+// r.ExpectChecksum is set.
+func (r *Reader) readRecord() ([]*fieldBuffer, error) {
+	if r.ExpectChecksum != "" {
+		return r.readChecksummed()
+	}
+	return r.readFields(false)
+}
+
+// ReadPositions reads one record from r like Read. It also returns the span
+// of every field as byte offsets relative to the start of the record. A span
+// covers the field as it appears in the input, including any quotes and
+// escape characters, and ends just before the following delimiter or line
+// terminator. Checksums are verified like by Read.
+func (r *Reader) ReadPositions() (record []string, spans [][2]int, err error) {
+	fields, err := r.readRecord()
+	for _, s := range fields {
+		record = append(record, s.String())
+		spans = append(spans, [2]int{int(s.start - r.recordStart), int(s.end - r.recordStart)})
+	}
+	return
+}
+
+// readFields reads the fields of one record. Fields may only be spilled to
+// disk if spill is set. Malformed records are handled according to r.OnError.
+func (r *Reader) readFields(spill bool) ([]*fieldBuffer, error) {
+	if r.OnError != nil && r.r.rec == nil {
+		r.r.rec = new(bytes.Buffer)
+	}
+	if r.r.rec != nil {
+		r.r.rec.Reset()
+	}
 
 	for {
-		field, err := r.readField()
-		record = append(record, field)
+		mark, line := 0, r.r.lines+1
+		if r.r.rec != nil {
+			r.r.resumeRecording()
+			mark = r.r.rec.Len()
+		}
+		r.recordStart = r.r.offset
+
+		fields, err := r.parseFields(spill)
+		perr, ok := err.(*ParseError)
+		if ok {
+			// Resynchronizing so that the next record starts on a new line.
+			r.skipLine()
+		} else if (err == nil || err == io.EOF) && !r.isTrailer(fields) {
+			perr = r.decodeFields(fields)
+		}
+		if perr == nil {
+			return fields, err
+		}
+		for _, s := range fields {
+			s.discard()
+		}
+
+		perr.Line = line
+		if r.OnError == nil {
+			return nil, perr
+		}
+		raw := append([]byte(nil), r.r.rec.Bytes()[mark:]...)
+		switch r.OnError(line, raw, perr) {
+		case Skip:
+		case Retry:
+			if r.r.recPaused {
+				// Can't put back what was not recorded.
+				break
+			}
+			if i := bytes.Index(raw, []byte(r.opts.LineTerminator)); i != -1 {
+				r.r.UnreadString(string(raw[i+len(r.opts.LineTerminator):]))
+			}
+		default:
+			return nil, perr
+		}
+	}
+}
+
+// parseFields reads the fields of one record.
+func (r *Reader) parseFields(spill bool) ([]*fieldBuffer, error) {
+	fields := make([]*fieldBuffer, 0, 2)
+	if !spill {
+		fields = r.buffers[:0]
+	}
+	r.delimiter = r.opts.Delimiter
+
+	for n := 0; ; n++ {
+		var s *fieldBuffer
+		if spill {
+			s = &fieldBuffer{
+				threshold: r.SpillThreshold,
+				dir:       r.SpillDir,
+				onSpill:   r.r.pauseRecording,
+			}
+		} else if n < len(r.buffers) {
+			s = r.buffers[n]
+			s.reset()
+		} else {
+			s = new(fieldBuffer)
+		}
+		s.start = r.r.offset
+		err := r.readField(s)
+		s.end = r.r.offset
+		if r.trimPadding() && err == nil {
+			// Padding following the closing quote of a quoted field.
+			err = r.skipPadding()
+		}
+		if ferr := s.finish(); err == nil {
+			err = ferr
+		}
+		fields = append(fields, s)
+		if !spill && len(fields) > len(r.buffers) {
+			r.buffers = fields
+		}
+		if err == ErrQuote {
+			return fields, &ParseError{Column: n, Err: err}
+		}
 		if err != nil {
-			return record, err
+			return fields, err
+		}
+
+		if r.MaxParseColumn > 0 && n+1 >= r.MaxParseColumn && !r.isTrailer(fields) {
+			return fields, r.skipRecord()
 		}
 
 		if nextIsLineTerminator, _ := r.nextIsLineTerminator(); nextIsLineTerminator {
 			// Skipping so that next read call is good to go.
-			err = r.skipLineTerminator()
 			// Error is not expected since it should be in the Unreader buffer, but
 			// might as well return it just in case.
-			return record, err
+			return fields, r.skipLineTerminator()
 		}
 		nextIsDelimiter, err := r.nextIsDelimiter()
 		if !nextIsDelimiter {
-			// Herein lies the devil!
-			return record, err
+			if err == nil {
+				// Only quoted fields can end without a delimiter following.
+				err = &ParseError{Column: n, Err: ErrQuote}
+			}
+			return fields, err
 		} else {
 			r.skipDelimiter()
 		}
-	}
 
-	// Required by Go 1.0 to compile. Unreachable code.
-	return record, nil
+		if n == 0 && r.DelimiterFunc != nil {
+			if delimiter := r.DelimiterFunc(s.String()); delimiter != 0 {
+				r.delimiter = delimiter
+			}
+		}
+	}
 }
 
-func (r *Reader) readField() (string, error) {
+func (r *Reader) readField(s *fieldBuffer) error {
+	if r.trimPadding() {
+		if err := r.skipPadding(); err != nil {
+			return err
+		}
+	}
 	char, _, err := r.r.ReadRune()
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	// Let the next individual reader functions handle this.
 	r.r.UnreadRune(char)
 
 	if char == r.opts.QuoteChar {
-		return r.readQuotedField()
+		return r.readQuotedField(s)
+	}
+	return r.readUnquotedField(s)
+}
+
+func (r *Reader) trimPadding() bool {
+	return r.TrimFieldPadding && r.delimiter != ' '
+}
+
+// skipPadding skips any spaces.
+func (r *Reader) skipPadding() error {
+	for {
+		char, _, err := r.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if char != ' ' {
+			r.r.UnreadRune(char)
+			return nil
+		}
 	}
-	return r.readUnquotedField()
 }
 
 func (r *Reader) nextIsLineTerminator() (bool, error) {
@@ -154,7 +480,7 @@ func (r *Reader) nextIsLineTerminator() (bool, error) {
 }
 
 func (r *Reader) nextIsDelimiter() (bool, error) {
-	return r.r.NextIsString(string(r.opts.Delimiter))
+	return r.r.NextIsString(string(r.delimiter))
 }
 
 func (r *Reader) skipLineTerminator() error {
@@ -167,56 +493,107 @@ func (r *Reader) skipLineTerminator() error {
 	return nil
 }
 
+// skipLine skips everything up to and including the next line terminator,
+// regardless of quoting.
+func (r *Reader) skipLine() error {
+	for {
+		if ok, err := r.nextIsLineTerminator(); ok {
+			return r.skipLineTerminator()
+		} else if err != nil && r.r.b.Len() == 0 {
+			return err
+		}
+		if _, _, err := r.r.ReadRune(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipRecord skips the rest of the record without parsing its fields. Quotes
+// are tracked so that line terminators within quoted fields are skipped too.
+func (r *Reader) skipRecord() error {
+	lineTerminator, _ := utf8.DecodeRuneInString(r.opts.LineTerminator)
+	enclosed := false
+	for {
+		char, _, err := r.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case char == r.opts.QuoteChar:
+			// Escaped quotes toggle twice, keeping us inside the field.
+			enclosed = !enclosed
+		case enclosed:
+			if r.opts.DoubleQuote == NoDoubleQuote && char == r.opts.EscapeChar {
+				if _, _, err := r.r.ReadRune(); err != nil {
+					return err
+				}
+			}
+		case char == lineTerminator:
+			// Only checking the full line terminator when it might start here.
+			r.r.UnreadRune(char)
+			if ok, _ := r.nextIsLineTerminator(); ok {
+				return r.skipLineTerminator()
+			}
+			r.r.ReadRune()
+		}
+	}
+}
+
 func (r *Reader) skipDelimiter() error {
 	_, _, err := r.r.ReadRune()
 	return err
 }
 
-func (r *Reader) readQuotedField() (string, error) {
+func (r *Reader) readQuotedField(s *fieldBuffer) error {
 	char, _, err := r.r.ReadRune()
 	if err != nil {
-		return "", err
+		return err
 	}
 	if char != r.opts.QuoteChar {
 		panic("Expected first character to be quote character.")
 	}
 
-	s := bytes.Buffer{}
+	// When escaping using an escape character, it is held back until we know
+	// whether it escapes a quote character.
+	escaped := false
 	for {
 		char, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			// Field never terminated.
+			return ErrQuote
+		}
 		if err != nil {
-			return s.String(), err
+			return err
 		}
 		if char != r.opts.QuoteChar {
-			s.WriteRune(char)
+			if escaped {
+				s.WriteRune(r.opts.EscapeChar)
+				escaped = false
+			}
+			if r.opts.DoubleQuote == NoDoubleQuote && char == r.opts.EscapeChar {
+				escaped = true
+			} else {
+				s.WriteRune(char)
+			}
 		} else {
 			switch r.opts.DoubleQuote {
 			case DoDoubleQuote:
 				char, _, err = r.r.ReadRune()
 				if err != nil {
-					return s.String(), err
+					return err
 				}
 				if char == r.opts.QuoteChar {
 					s.WriteRune(char)
 				} else {
 					r.r.UnreadRune(char)
-					return s.String(), nil
+					return nil
 				}
 			case NoDoubleQuote:
-				if s.Len() == 0 {
-					return s.String(), nil
-				}
-				lastRune, size := utf8.DecodeLastRuneInString(s.String())
-				if lastRune == utf8.RuneError && size == 1 {
-					panic("Field contained malformed rune.")
-				}
-				if lastRune == r.opts.EscapeChar {
-					// Replace previous escape character.
-					s.Truncate(s.Len() - utf8.RuneLen(char))
-					s.WriteRune(char)
-				} else {
-					return s.String(), nil
+				if !escaped {
+					return nil
 				}
+				escaped = false
+				s.WriteRune(char)
 			default:
 				panic("Unrecognized double quote mode.")
 			}
@@ -224,15 +601,18 @@ func (r *Reader) readQuotedField() (string, error) {
 	}
 
 	// Required by Go 1.0 to compile. Unreachable code.
-	return s.String(), nil
+	return nil
 }
 
-func (r *Reader) readUnquotedField() (string, error) {
-	// TODO: Use bytes.Buffer
-	s := bytes.Buffer{}
+func (r *Reader) readUnquotedField(s *fieldBuffer) error {
+	// Number of spaces held back since they might be trailing padding.
+	padding := 0
 	for {
 		char, _, err := r.r.ReadRune()
-		if err != nil || char == r.opts.Delimiter {
+		if err != nil {
+			return err
+		}
+		if char == r.delimiter {
 			// TODO Can a non quoted string be escaped? In that case, it should be
 			// handled here. Should probably have a look at how Python's csv module
 			// is handling this.
@@ -241,15 +621,20 @@ func (r *Reader) readUnquotedField() (string, error) {
 			// compatible with readQuotedField().
 			r.r.UnreadRune(char)
 
-			return s.String(), err
+			return nil
+		} else if char == ' ' && r.trimPadding() {
+			padding++
 		} else {
+			for ; padding > 0; padding-- {
+				s.WriteRune(' ')
+			}
 			s.WriteRune(char)
 		}
 		if ok, _ := r.nextIsLineTerminator(); ok {
-			return s.String(), nil
+			return nil
 		}
 	}
 
 	// Required by Go 1.0 to compile. Unreachable code.
-	return s.String(), nil
+	return nil
 }
@@ -5,8 +5,11 @@ package csv
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"testing/quick"
 )
@@ -116,6 +119,247 @@ func TestReadingCommaDelimitedFile(t *testing.T) {
 	}
 }
 
+func TestReadingEscapedQuotes(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	b.WriteString("\"a\\\"b\" \"c\\d\"\n")
+	r := NewDialectReader(b, Dialect{DoubleQuote: NoDoubleQuote})
+
+	err := testReadingSingleLine(t, r, []string{"a\"b", "c\\d"})
+	if err != nil && err != io.EOF {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func testReadingPositions(t *testing.T, r *Reader, expected []string, expectedSpans [][2]int) {
+	record, spans, err := r.ReadPositions()
+	if err != nil && err != io.EOF {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(record, expected) {
+		t.Error("Unexpected record:", record, "Expected:", expected)
+	}
+	if !reflect.DeepEqual(spans, expectedSpans) {
+		t.Error("Unexpected spans:", spans, "Expected:", expectedSpans)
+	}
+}
+
+func TestReadPositions(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	b.WriteString("a \"b\"\"c\" d\nåäö \"ö\"\n")
+	r := NewReader(b)
+
+	testReadingPositions(t, r, []string{"a", "b\"c", "d"}, [][2]int{{0, 1}, {2, 8}, {9, 10}})
+	testReadingPositions(t, r, []string{"åäö", "ö"}, [][2]int{{0, 6}, {7, 11}})
+}
+
+func TestReadPositionsEscaped(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	b.WriteString("\"x\\\"y\" z\n")
+	r := NewDialectReader(b, Dialect{DoubleQuote: NoDoubleQuote})
+
+	testReadingPositions(t, r, []string{"x\"y", "z"}, [][2]int{{0, 6}, {7, 8}})
+}
+
+const malformed = "a b c\n\"d\"x e f\ng h i\n\"j k\nm n o\np q r\n"
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(strings.NewReader(malformed))
+	testReadingSingleLine(t, r, []string{"a", "b", "c"})
+	_, err := r.Read()
+	if perr, ok := err.(*ParseError); !ok || *perr != (ParseError{Line: 2, Column: 0, Err: ErrQuote}) {
+		t.Error("Unexpected error:", err)
+	}
+	if err := testReadingSingleLine(t, r, []string{"g", "h", "i"}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	_, err = r.Read()
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 4 {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestParseErrorQuotes(t *testing.T) {
+	t.Parallel()
+
+	// Unterminated and extraneous quotes are rejected without OnError too.
+	for _, input := range []string{"\"abc", "\"a\"x b\n"} {
+		data, err := NewReader(strings.NewReader(input)).ReadAll()
+		if perr, ok := err.(*ParseError); !ok || *perr != (ParseError{Line: 1, Column: 0, Err: ErrQuote}) {
+			t.Errorf("Unexpected error for %q: %v", input, err)
+		}
+		if data != nil {
+			t.Error("Unexpected output:", data)
+		}
+	}
+}
+
+func testOnError(t *testing.T, action Action) ([][]string, []int, error) {
+	var lines []int
+	r := NewReader(strings.NewReader(malformed))
+	r.OnError = func(line int, raw []byte, err error) Action {
+		lines = append(lines, line)
+		if perr, ok := err.(*ParseError); !ok || perr.Line != line || perr.Err != ErrQuote {
+			t.Error("Unexpected error:", err)
+		}
+		if line == 2 && string(raw) != "\"d\"x e f\n" {
+			t.Errorf("Unexpected raw record: %q", raw)
+		}
+		if line == 4 && string(raw) != "\"j k\nm n o\np q r\n" {
+			t.Errorf("Unexpected raw record: %q", raw)
+		}
+		return action
+	}
+	data, err := r.ReadAll()
+	return data, lines, err
+}
+
+func TestOnErrorSkip(t *testing.T) {
+	t.Parallel()
+
+	data, lines, err := testOnError(t, Skip)
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 4}) {
+		t.Error("Unexpected lines:", lines)
+	}
+	if !reflect.DeepEqual(data, [][]string{{"a", "b", "c"}, {"g", "h", "i"}}) {
+		t.Error("Unexpected output:", data)
+	}
+}
+
+func TestOnErrorRetry(t *testing.T) {
+	t.Parallel()
+
+	data, lines, err := testOnError(t, Retry)
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 4}) {
+		t.Error("Unexpected lines:", lines)
+	}
+	expected := [][]string{{"a", "b", "c"}, {"g", "h", "i"}, {"m", "n", "o"}, {"p", "q", "r"}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Error("Unexpected output:", data)
+	}
+}
+
+func TestOnErrorAbort(t *testing.T) {
+	t.Parallel()
+
+	data, lines, err := testOnError(t, Abort)
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 2 {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(lines, []int{2}) {
+		t.Error("Unexpected lines:", lines)
+	}
+	if data != nil {
+		t.Error("Unexpected output:", data)
+	}
+}
+
+func TestTrimFieldPadding(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	b.WriteString("0001|ADAM WEST |  120.50\n0004|\" MORTY\"  |   42.00\n")
+	r := NewDialectReader(b, Dialect{Delimiter: '|'})
+	r.TrimFieldPadding = true
+
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	equals := reflect.DeepEqual(data, [][]string{
+		{"0001", "ADAM WEST", "120.50"},
+		{"0004", " MORTY", "42.00"},
+	})
+	if !equals {
+		t.Errorf("Unexpected output: %q", data)
+	}
+}
+
+func TestTrimFieldPaddingSpaceDelimiter(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	b.WriteString("a  b\n")
+	r := NewReader(b)
+	r.TrimFieldPadding = true
+
+	err := testReadingSingleLine(t, r, []string{"a", "", "b"})
+	if err != nil && err != io.EOF {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestMaxParseColumn(t *testing.T) {
+	t.Parallel()
+
+	b := new(bytes.Buffer)
+	b.WriteString("a b \"c\nd\" e\n\"f g\" h\ni\n\"j\"\"k\" \"l m\" n\no p \"q\"\"\nr\"\n")
+	r := NewReader(b)
+	r.MaxParseColumn = 2
+
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	equals := reflect.DeepEqual(data, [][]string{
+		{"a", "b"},
+		{"f g", "h"},
+		{"i"},
+		{"j\"k", "l m"},
+		{"o", "p"},
+	})
+	if !equals {
+		t.Errorf("Unexpected output: %q", data)
+	}
+}
+
+func benchmarkReadingWideFile(b *testing.B, maxParseColumn int) {
+	row := new(bytes.Buffer)
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			row.WriteByte(',')
+		}
+		if i%2 == 0 {
+			fmt.Fprintf(row, "\"field, %d\"", i)
+		} else {
+			fmt.Fprintf(row, "field%d", i)
+		}
+	}
+	row.WriteByte('\n')
+	data := bytes.Repeat(row.Bytes(), 100)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewDialectReader(bytes.NewReader(data), Dialect{Delimiter: ','})
+		r.MaxParseColumn = maxParseColumn
+		if _, err := r.ReadAll(); err != nil {
+			b.Fatal("Unexpected error:", err)
+		}
+	}
+}
+
+func BenchmarkReadingWideFile(b *testing.B) {
+	benchmarkReadingWideFile(b, 0)
+}
+
+func BenchmarkReadingWideFileMaxParseColumn(b *testing.B) {
+	benchmarkReadingWideFile(b, 2)
+}
+
 func TestReadAll(t *testing.T) {
 	t.Parallel()
 
@@ -144,6 +388,54 @@ func TestReadAll(t *testing.T) {
 	}
 }
 
+func TestReadAllVaryingWidths(t *testing.T) {
+	t.Parallel()
+
+	// Field buffers are reused between records.
+	r := NewReader(strings.NewReader("aaaa \"b b\" c\nd\n\"\" ff\n"))
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !reflect.DeepEqual(data, [][]string{{"aaaa", "b b", "c"}, {"d"}, {"", "ff"}}) {
+		t.Errorf("Unexpected output: %q", data)
+	}
+}
+
+func TestDelimiterFunc(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("./Fixtures/multidelimited.csv")
+	if err != nil {
+		t.Fatal("Could not open fixture:", err)
+	}
+	defer f.Close()
+
+	r := NewDialectReader(f, Dialect{Delimiter: ':'})
+	r.DelimiterFunc = func(firstField string) rune {
+		switch firstField {
+		case "HDR":
+			return ','
+		case "ROW":
+			return ';'
+		}
+		return 0
+	}
+	data, err := r.ReadAll()
+	if err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	equals := reflect.DeepEqual(data, [][]string{
+		{"HDR", "2024-01-01", "batch-7", "3"},
+		{"ROW", "widget", "3", "9.99"},
+		{"ROW", "gadget; large", "1", "19.50"},
+		{"TRL", "3"},
+	})
+	if !equals {
+		t.Error("Unexpected output:", data)
+	}
+}
+
 func testReaderQuick(t *testing.T, quoting int) {
 	f := func(records [][]string, doubleQuote bool, escapeChar, del, quoteChar rune, lt string) bool {
 		dialect := Dialect{
@@ -13,6 +13,12 @@ import (
 //
 // Can be created by calling either NewWriter or using NewDialectWriter.
 type Writer struct {
+	// Indices of columns whose values are base64 encoded when written. Encoded
+	// values never contain a line terminator, nor a delimiter or quote
+	// character unless it is one of '+', '/' or '='. Read them using a Reader
+	// with the same Base64Columns.
+	Base64Columns []int
+
 	opts Dialect
 	w    *bufio.Writer
 }
@@ -139,6 +145,9 @@ func (w Writer) Write(record []string) (err error) {
 				return
 			}
 		}
+		if containsColumn(w.Base64Columns, n) {
+			field = base64Encoding.EncodeToString([]byte(field))
+		}
 		if err = w.writeField(field); err != nil {
 			return
 		}
@@ -147,6 +156,16 @@ func (w Writer) Write(record []string) (err error) {
 	return
 }
 
+// WriteDeclaration writes a line declaring the dialect of w, which can be
+// read back using ParseDeclaration. Call it before writing any records. See
+// DeclarationPrefix.
+func (w Writer) WriteDeclaration() error {
+	if err := w.writeString(FormatDeclaration(w.opts)); err != nil {
+		return err
+	}
+	return w.writeNewline()
+}
+
 // WriteAll writes multiple CSV records to w using Write and then calls Flush.
 func (w Writer) WriteAll(records [][]string) (err error) {
 	for _, record := range records {